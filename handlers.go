@@ -2,35 +2,46 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
-	"image"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"log"
 	"mime"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
+	"reddit-gallery-dl/store"
+
 	_ "golang.org/x/image/webp"
 )
 
+// maxImageBytes guards a single worker's in-memory buffer against runaway
+// downloads (e.g. a misbehaving host streaming well past its Content-Length).
+const maxImageBytes = 100 << 20
+
 type Server struct {
-	reddit *RedditClient
-	tmpl   *template.Template
+	reddit  *RedditClient
+	tmpl    *template.Template
+	jobs    *jobStore
+	history *store.Store
 }
 
-func NewServer(tmpl *template.Template) *Server {
+func NewServer(tmpl *template.Template, history *store.Store) *Server {
 	return &Server{
-		reddit: NewRedditClient(),
-		tmpl:   tmpl,
+		reddit:  NewRedditClient(),
+		tmpl:    tmpl,
+		jobs:    newJobStore(),
+		history: history,
 	}
 }
 
@@ -39,7 +50,11 @@ func (s *Server) Routes() *http.ServeMux {
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/download-zip", s.handleDownloadZip)
+	mux.HandleFunc("/download-zip/", s.handleDownloadArchive)
 	mux.HandleFunc("/download-single", s.handleDownloadSingle)
+	mux.HandleFunc("/progress/", s.handleProgress)
+	mux.HandleFunc("/history", s.handleHistoryList)
+	mux.HandleFunc("/history/", s.handleHistoryItem)
 	return mux
 }
 
@@ -50,7 +65,7 @@ type Alert struct {
 
 type TemplateData struct {
 	Title  string
-	Images []string
+	Images []GalleryItem
 	URL    string
 	Alert  *Alert
 }
@@ -76,6 +91,8 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	go s.saveGalleryHistory(context.Background(), gallery)
+
 	s.tmpl.ExecuteTemplate(w, "index.html", TemplateData{
 		Title:  gallery.Title,
 		Images: gallery.Images,
@@ -92,10 +109,36 @@ func (s *Server) handleDownloadSingle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.serveSingleImage(w, r.Context(), rawURL, format)
+	if MediaKind(r.URL.Query().Get("kind")) == MediaKindVideo {
+		item := GalleryItem{
+			URL:     rawURL,
+			DashURL: r.URL.Query().Get("dash_url"),
+			HLSURL:  r.URL.Query().Get("hls_url"),
+			Kind:    MediaKindVideo,
+		}
+		s.serveSingleVideo(w, r.Context(), item)
+		return
+	}
+
+	s.serveSingleImage(w, r.Context(), rawURL, format, parseResizeOptions(r.URL.Query()))
 }
 
-func (s *Server) serveSingleImage(w http.ResponseWriter, ctx context.Context, rawURL, format string) {
+func (s *Server) serveSingleVideo(w http.ResponseWriter, ctx context.Context, item GalleryItem) {
+	body, err := s.reddit.StreamVideo(ctx, item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\"video.mp4\"")
+	w.Header().Set("Content-Type", "video/mp4")
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Error streaming video: %v", err)
+	}
+}
+
+func (s *Server) serveSingleImage(w http.ResponseWriter, ctx context.Context, rawURL, format string, resize ResizeOptions) {
 	body, ext, err := s.reddit.StreamImage(ctx, rawURL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
@@ -122,11 +165,29 @@ func (s *Server) serveSingleImage(w http.ResponseWriter, ctx context.Context, ra
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
 	w.Header().Set("Content-Type", mime.TypeByExtension(finalExt))
 
-	if err := s.streamImage(body, format, w); err != nil {
+	if err := s.streamImage(body, format, resize, w); err != nil {
 		log.Printf("Error streaming single image: %v", err)
 	}
 }
 
+// parseResizeOptions reads max_width, max_height, quality and fit from form
+// or query params into a ResizeOptions. Missing or invalid values leave the
+// corresponding field at its zero value (disabled).
+func parseResizeOptions(values url.Values) ResizeOptions {
+	var resize ResizeOptions
+	if n, err := strconv.Atoi(values.Get("max_width")); err == nil && n > 0 {
+		resize.MaxWidth = n
+	}
+	if n, err := strconv.Atoi(values.Get("max_height")); err == nil && n > 0 {
+		resize.MaxHeight = n
+	}
+	if n, err := strconv.Atoi(values.Get("quality")); err == nil && n > 0 {
+		resize.Quality = n
+	}
+	resize.Fit = values.Get("fit")
+	return resize
+}
+
 func (s *Server) handleDownloadZip(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -135,79 +196,271 @@ func (s *Server) handleDownloadZip(w http.ResponseWriter, r *http.Request) {
 
 	r.ParseForm()
 	urls := r.Form["image_urls"]
+	kinds := r.Form["image_kinds"]
+	dashURLs := r.Form["image_dash_urls"]
+	hlsURLs := r.Form["image_hls_urls"]
 	format := r.FormValue("format")
+	resize := parseResizeOptions(r.Form)
 	if len(urls) == 0 {
 		http.Error(w, "No images selected", http.StatusBadRequest)
 		return
 	}
 
-	if len(urls) == 1 {
-		s.serveSingleImage(w, r.Context(), urls[0], format)
+	items := make([]GalleryItem, len(urls))
+	for i, u := range urls {
+		item := GalleryItem{URL: u, Kind: MediaKindImage}
+		if i < len(kinds) && MediaKind(kinds[i]) == MediaKindVideo {
+			item.Kind = MediaKindVideo
+		}
+		if i < len(dashURLs) {
+			item.DashURL = dashURLs[i]
+		}
+		if i < len(hlsURLs) {
+			item.HLSURL = hlsURLs[i]
+		}
+		items[i] = item
+	}
+
+	if len(items) == 1 {
+		if items[0].Kind == MediaKindVideo {
+			s.serveSingleVideo(w, r.Context(), items[0])
+		} else {
+			s.serveSingleImage(w, r.Context(), items[0].URL, format, resize)
+		}
 		return
 	}
 
-	title := cleanFilename(r.FormValue("page_title"))
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", title))
+	job := newZipJob(items, format, resize, cleanFilename(r.FormValue("page_title")))
+	s.jobs.add(job)
+	go s.runZipJob(job)
+	go s.reapJob(job)
 
-	z := zip.NewWriter(w)
-	defer z.Close()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"job_id": job.id})
+}
 
-	for i, u := range urls {
-		if r.Context().Err() != nil {
-			log.Println("Client disconnected, stopping zip stream.")
+// jobTTL bounds how long an unclaimed job's downloads and jobStore entry are
+// kept around if nobody ever polls /progress or fetches the archive.
+const jobTTL = 10 * time.Minute
+
+// reapJob frees a job's resources if its archive is never claimed: it
+// cancels any in-flight downloads and removes the job from jobStore so an
+// abandoned download doesn't hold a jobStore entry or imageSemaphore slots
+// forever.
+func (s *Server) reapJob(job *zipJob) {
+	select {
+	case <-job.claimed:
+	case <-time.After(jobTTL):
+		job.cancel()
+		s.jobs.delete(job.id)
+	}
+}
+
+// runZipJob downloads every image in job through the bounded worker pool,
+// publishing a ProgressEvent as each one starts and finishes. It runs
+// independently of the original HTTP request so the browser is free to
+// disconnect and reattach via /progress/{jobID}; job.ctx is only canceled by
+// reapJob, once the job has gone unclaimed for too long.
+func (s *Server) runZipJob(job *zipJob) {
+	ctx := job.ctx
+	results := make([]imageResult, len(job.items))
+
+	var wg sync.WaitGroup
+	for i, item := range job.items {
+		if err := s.reddit.acquireImageSlot(ctx); err != nil {
+			results[i] = imageResult{err: err}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item GalleryItem) {
+			defer wg.Done()
+			defer s.reddit.releaseImageSlot()
+
+			job.events <- ProgressEvent{Index: i, URL: item.URL, Status: JobStatusDownloading}
+
+			res := s.fetchOneMedia(ctx, item, job.format, job.resize)
+			results[i] = res
+
+			if res.err != nil {
+				job.events <- ProgressEvent{Index: i, URL: item.URL, Status: JobStatusError}
+			} else {
+				job.events <- ProgressEvent{Index: i, URL: item.URL, Status: JobStatusDone, Bytes: len(res.data)}
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	job.results = results
+	close(job.events)
+	close(job.done)
+	job.cancel()
+}
+
+// handleProgress streams a zipJob's ProgressEvents as server-sent events
+// until the job completes or the client disconnects.
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimPrefix(r.URL.Path, "/progress/")
+	job, ok := s.jobs.get(jobID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case event, ok := <-job.events:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
 			return
 		}
+	}
+}
 
-		body, ext, err := s.reddit.StreamImage(r.Context(), u)
-		if err != nil {
-			log.Printf("Skipping %s: %v", u, err)
+// handleDownloadArchive waits for a zipJob to finish, then streams its
+// images as a zip. It's the follow-up call to the job ID returned by
+// handleDownloadZip, requested at /download-zip/{jobID}/archive.
+func (s *Server) handleDownloadArchive(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/download-zip/"), "/archive")
+	job, ok := s.jobs.get(jobID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	select {
+	case <-job.done:
+	case <-r.Context().Done():
+		return
+	}
+	// Only mark claimed once the job has actually finished and we're about
+	// to serve it; marking claimed any earlier would stop reapJob from ever
+	// cleaning up a job whose requester disconnects before it's done.
+	job.markClaimed()
+	defer s.jobs.delete(jobID)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", job.title))
+
+	z := zip.NewWriter(w)
+	defer z.Close()
+
+	for i, res := range job.results {
+		if res.err != nil {
+			log.Printf("Skipping %s: %v", job.items[i].URL, res.err)
 			continue
 		}
 
-		finalExt := ext
-		if format != "" && format != "original" {
-			finalExt = "." + format
-			if format == "jpeg" {
-				finalExt = ".jpg"
-			}
+		name := fmt.Sprintf("image_%03d%s", i+1, res.ext)
+		if job.items[i].Kind == MediaKindVideo {
+			name = fmt.Sprintf("video_%03d%s", i+1, res.ext)
 		}
 
-		f, err := z.Create(fmt.Sprintf("image_%03d%s", i+1, finalExt))
+		f, err := z.Create(name)
 		if err != nil {
-			body.Close()
 			log.Printf("Zip create error: %v", err)
 			continue
 		}
 
-		if err := s.streamImage(body, format, f); err != nil {
-			log.Printf("Zip write error for %s: %v", u, err)
+		if _, err := f.Write(res.data); err != nil {
+			log.Printf("Zip write error for %s: %v", job.items[i].URL, err)
 		}
-		body.Close()
 	}
 }
 
-// streamImage streams the image from src to dst, converting it on-the-fly if needed.
-func (s *Server) streamImage(src io.Reader, format string, dst io.Writer) error {
-	if format == "" || format == "original" {
-		_, err := io.Copy(dst, src)
-		return err
+// imageResult holds the outcome of downloading a single gallery image.
+type imageResult struct {
+	ext  string
+	data []byte
+	err  error
+}
+
+// fetchOneMedia downloads a single gallery item. Videos are streamed
+// straight through as mp4 with no format conversion or resizing.
+func (s *Server) fetchOneMedia(ctx context.Context, item GalleryItem, format string, resize ResizeOptions) imageResult {
+	if item.Kind == MediaKindVideo {
+		return s.fetchOneVideo(ctx, item)
 	}
 
-	img, _, err := image.Decode(src)
+	body, ext, err := s.reddit.StreamImage(ctx, item.URL)
 	if err != nil {
-		return fmt.Errorf("decode: %w", err)
+		return imageResult{err: err}
 	}
+	defer body.Close()
 
-	switch format {
-	case "jpg", "jpeg":
-		return jpeg.Encode(dst, img, &jpeg.Options{Quality: 90})
-	case "png":
-		return png.Encode(dst, img)
-	case "gif":
-		return gif.Encode(dst, img, nil)
+	finalExt := ext
+	if format != "" && format != "original" {
+		finalExt = "." + format
+		if format == "jpeg" {
+			finalExt = ".jpg"
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.streamImage(io.LimitReader(body, maxImageBytes+1), format, resize, &buf); err != nil {
+		return imageResult{err: err}
+	}
+	if buf.Len() > maxImageBytes {
+		return imageResult{err: fmt.Errorf("image exceeds %d byte limit", maxImageBytes)}
+	}
+
+	return imageResult{ext: finalExt, data: buf.Bytes()}
+}
+
+func (s *Server) fetchOneVideo(ctx context.Context, item GalleryItem) imageResult {
+	body, err := s.reddit.StreamVideo(ctx, item)
+	if err != nil {
+		return imageResult{err: err}
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(body, maxImageBytes+1)); err != nil {
+		return imageResult{err: err}
+	}
+	if buf.Len() > maxImageBytes {
+		return imageResult{err: fmt.Errorf("video exceeds %d byte limit", maxImageBytes)}
+	}
+
+	return imageResult{ext: ".mp4", data: buf.Bytes()}
+}
+
+// streamImage streams the image from src to dst, converting and resizing it
+// on-the-fly if needed. The passthrough case is handled here to avoid
+// buffering untouched bytes; any real conversion is delegated to
+// convertImage so the decode/resize/encode logic lives in exactly one place.
+func (s *Server) streamImage(src io.Reader, format string, resize ResizeOptions, dst io.Writer) error {
+	if (format == "" || format == "original") && !resize.enabled() {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	data, _, err := convertImage(src, format, resize)
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("unsupported format: %s", format)
+	_, err = dst.Write(data)
+	return err
 }
 
 func cleanFilename(s string) string {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRunZipJobPreservesItemOrder confirms that even though items download
+// concurrently and may finish in any order, job.results stays indexed by the
+// original item order, not completion order.
+func TestRunZipJobPreservesItemOrder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("slow-data"))
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast-data"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	s := &Server{reddit: NewRedditClient(), jobs: newJobStore()}
+	items := []GalleryItem{
+		{URL: srv.URL + "/slow", Kind: MediaKindImage},
+		{URL: srv.URL + "/fast", Kind: MediaKindImage},
+	}
+	job := newZipJob(items, "", ResizeOptions{}, "test")
+
+	s.runZipJob(job)
+
+	if len(job.results) != 2 {
+		t.Fatalf("got %d results, want 2", len(job.results))
+	}
+	if got := string(job.results[0].data); got != "slow-data" {
+		t.Errorf("results[0] = %q, want slow-data (item order, not completion order)", got)
+	}
+	if got := string(job.results[1].data); got != "fast-data" {
+		t.Errorf("results[1] = %q, want fast-data (item order, not completion order)", got)
+	}
+}
+
+// TestRunZipJobEventsMatchResults confirms every item publishes a
+// downloading event followed by a done event carrying its own index, and
+// that the events channel is closed once all items are accounted for.
+func TestRunZipJobEventsMatchResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	s := &Server{reddit: NewRedditClient(), jobs: newJobStore()}
+	items := []GalleryItem{
+		{URL: srv.URL + "/a", Kind: MediaKindImage},
+		{URL: srv.URL + "/b", Kind: MediaKindImage},
+	}
+	job := newZipJob(items, "", ResizeOptions{}, "test")
+
+	s.runZipJob(job)
+
+	seen := map[int][]JobStatus{}
+	for event := range job.events {
+		seen[event.Index] = append(seen[event.Index], event.Status)
+	}
+
+	for i := range items {
+		statuses := seen[i]
+		if len(statuses) != 2 || statuses[0] != JobStatusDownloading || statuses[1] != JobStatusDone {
+			t.Errorf("item %d got events %v, want [downloading done]", i, statuses)
+		}
+	}
+
+	select {
+	case <-job.done:
+	default:
+		t.Error("job.done was not closed after runZipJob returned")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"reddit-gallery-dl/store"
+)
+
+const (
+	defaultHistoryLimit = 25
+	maxHistoryLimit     = 100
+)
+
+// saveGalleryHistory best-effort records a fetched gallery in history,
+// caching each image's bytes to disk. Caching is independent of the page
+// request, so failures here are logged, not surfaced to the user.
+func (s *Server) saveGalleryHistory(ctx context.Context, g *Gallery) {
+	if s.history == nil {
+		return
+	}
+
+	urls := make([]string, 0, len(g.Images))
+	for _, item := range g.Images {
+		if item.Kind == MediaKindImage {
+			urls = append(urls, item.URL)
+		}
+	}
+
+	fetch := func(u string) ([]byte, string, error) {
+		body, ext, err := s.reddit.StreamImage(ctx, u)
+		if err != nil {
+			return nil, "", err
+		}
+		defer body.Close()
+		data, _, err := convertImage(body, "", ResizeOptions{})
+		return data, ext, err
+	}
+
+	record := store.Gallery{
+		PostURL:   g.URL,
+		Title:     g.Title,
+		Subreddit: g.Subreddit,
+		Author:    g.Author,
+		FetchedAt: time.Now(),
+	}
+	if _, err := s.history.SaveGallery(ctx, record, urls, fetch); err != nil {
+		log.Printf("Failed to save gallery history: %v", err)
+	}
+}
+
+// HistoryListData is the template data for the history browse page.
+type HistoryListData struct {
+	Galleries []store.Gallery
+}
+
+// HistoryDetailData is the template data for a single history entry,
+// re-rendered from cache.
+type HistoryDetailData struct {
+	Gallery *store.Gallery
+	Images  []store.Image
+}
+
+// wantsJSON reports whether a history request should get the JSON API
+// response instead of the browsable HTML page; the HTML page is what a
+// browser navigating to the link gets by default.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// handleHistoryList serves GET /history: a paginated, subreddit-filterable
+// list of previously fetched galleries, rendered as a browse page (or JSON
+// for API callers).
+func (s *Server) handleHistoryList(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= maxHistoryLimit {
+		limit = n
+	}
+	page := 0
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 0 {
+		page = n
+	}
+
+	galleries, err := s.history.ListGalleries(r.Context(), r.URL.Query().Get("subreddit"), limit, page*limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(galleries)
+		return
+	}
+
+	s.tmpl.ExecuteTemplate(w, "history.html", HistoryListData{Galleries: galleries})
+}
+
+// handleHistoryGet serves GET /history/{id}: a recorded gallery and its
+// cached images, re-rendered without hitting Reddit again, as a detail page
+// (or JSON for API callers).
+func (s *Server) handleHistoryGet(w http.ResponseWriter, r *http.Request) {
+	id, err := historyID(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gallery, images, err := s.history.GetGallery(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"gallery": gallery,
+			"images":  images,
+		})
+		return
+	}
+
+	s.tmpl.ExecuteTemplate(w, "history_detail.html", HistoryDetailData{Gallery: gallery, Images: images})
+}
+
+// handleHistoryImage serves GET /history/{id}/images/{imageID}, streaming a
+// cached image's bytes from disk. The image must belong to the requested
+// gallery, so one history entry's link can't be used to pull another's
+// cached file.
+func (s *Server) handleHistoryImage(w http.ResponseWriter, r *http.Request, galleryID, imageID int64) {
+	img, err := s.history.GetImage(r.Context(), galleryID, imageID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, img.CachePath)
+}
+
+// handleHistoryDelete serves DELETE /history/{id}, removing the gallery's
+// history row. Cached image bytes are left for the cache's size cap to
+// evict later.
+func (s *Server) handleHistoryDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := historyID(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.history.DeleteGallery(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHistoryItem dispatches everything under /history/{id}: a
+// /history/{id}/images/{imageID} suffix serves the cached file; otherwise
+// GET re-renders the cached gallery and DELETE removes it from history.
+func (s *Server) handleHistoryItem(w http.ResponseWriter, r *http.Request) {
+	if galleryID, imageID, ok := parseHistoryImagePath(r.URL.Path); ok {
+		s.handleHistoryImage(w, r, galleryID, imageID)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		s.handleHistoryDelete(w, r)
+		return
+	}
+	s.handleHistoryGet(w, r)
+}
+
+func historyID(requestPath string) (int64, error) {
+	idStr := strings.TrimPrefix(requestPath, "/history/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid history id %q", idStr)
+	}
+	return id, nil
+}
+
+// parseHistoryImagePath matches /history/{galleryID}/images/{imageID}.
+func parseHistoryImagePath(requestPath string) (galleryID, imageID int64, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(requestPath, "/history/"), "/")
+	if len(parts) != 3 || parts[1] != "images" {
+		return 0, 0, false
+	}
+	gid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	iid, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return gid, iid, true
+}
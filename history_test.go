@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"reddit-gallery-dl/store"
+)
+
+func newTestServerWithHistory(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	history, err := store.Open(filepath.Join(dir, "history.db"), filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { history.Close() })
+
+	return &Server{reddit: NewRedditClient(), jobs: newJobStore(), history: history}
+}
+
+// TestHandleHistoryImageRejectsCrossGalleryAccess confirms a cached image
+// can only be fetched through the gallery it actually belongs to, not by
+// pairing its image ID with an arbitrary other gallery ID.
+func TestHandleHistoryImageRejectsCrossGalleryAccess(t *testing.T) {
+	s := newTestServerWithHistory(t)
+	ctx := context.Background()
+
+	fetch := func(u string) ([]byte, string, error) {
+		return []byte("bytes-for-" + u), ".jpg", nil
+	}
+
+	id1, err := s.history.SaveGallery(ctx, store.Gallery{PostURL: "p1", Title: "g1", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/a.jpg"}, fetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+	id2, err := s.history.SaveGallery(ctx, store.Gallery{PostURL: "p2", Title: "g2", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/b.jpg"}, fetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+
+	_, images1, err := s.history.GetGallery(ctx, id1)
+	if err != nil || len(images1) != 1 {
+		t.Fatalf("GetGallery(id1): images=%v err=%v", images1, err)
+	}
+
+	// Same gallery: should serve the file.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/history/ignored", nil)
+	s.handleHistoryImage(rec, req, id1, images1[0].ID)
+	if rec.Code != http.StatusOK {
+		t.Errorf("own gallery: got status %d, want 200", rec.Code)
+	}
+
+	// Different gallery: must not serve the file.
+	rec2 := httptest.NewRecorder()
+	s.handleHistoryImage(rec2, req, id2, images1[0].ID)
+	if rec2.Code != http.StatusNotFound {
+		t.Errorf("cross-gallery access: got status %d, want 404", rec2.Code)
+	}
+}
@@ -8,27 +8,69 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
 
+	"golang.org/x/image/draw"
 	_ "golang.org/x/image/webp"
 )
 
-func convertImage(input io.Reader, format string) ([]byte, string, error) {
-	if format == "" || format == "original" {
+// ResizeOptions controls on-the-fly resizing applied before an image is
+// re-encoded. A zero value leaves the image untouched.
+type ResizeOptions struct {
+	MaxWidth  int
+	MaxHeight int
+	Quality   int
+	Fit       string // "contain" (default), "cover", or "scale"
+}
+
+func (o ResizeOptions) enabled() bool {
+	return o.MaxWidth > 0 || o.MaxHeight > 0
+}
+
+func (o ResizeOptions) qualityOr(def int) int {
+	if o.Quality > 0 {
+		return o.Quality
+	}
+	return def
+}
+
+func convertImage(input io.Reader, format string, resize ResizeOptions) ([]byte, string, error) {
+	if (format == "" || format == "original") && !resize.enabled() {
 		data, err := io.ReadAll(input)
 		return data, "", err
 	}
 
-	img, _, err := image.Decode(input)
+	raw, err := io.ReadAll(input)
+	if err != nil {
+		return nil, "", fmt.Errorf("read error: %w", err)
+	}
+
+	if resize.enabled() {
+		if _, formatName, err := image.DecodeConfig(bytes.NewReader(raw)); err == nil && formatName == "gif" {
+			return resizeAnimatedGIF(raw, resize)
+		}
+	}
+
+	img, srcFormat, err := image.Decode(bytes.NewReader(raw))
 	if err != nil {
 		return nil, "", fmt.Errorf("decode error: %w", err)
 	}
 
+	if resize.enabled() {
+		img = resizeImage(img, resize)
+	}
+
+	outFormat := format
+	if outFormat == "" || outFormat == "original" {
+		outFormat = srcFormat
+	}
+
 	var buf bytes.Buffer
 	var ext string
 
-	switch format {
+	switch outFormat {
 	case "jpg", "jpeg":
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: resize.qualityOr(90)})
 		ext = ".jpg"
 	case "png":
 		err = png.Encode(&buf, img)
@@ -37,7 +79,7 @@ func convertImage(input io.Reader, format string) ([]byte, string, error) {
 		err = gif.Encode(&buf, img, nil)
 		ext = ".gif"
 	default:
-		return nil, "", fmt.Errorf("unsupported format: %s", format)
+		return nil, "", fmt.Errorf("unsupported format: %s", outFormat)
 	}
 
 	if err != nil {
@@ -46,3 +88,75 @@ func convertImage(input io.Reader, format string) ([]byte, string, error) {
 
 	return buf.Bytes(), ext, nil
 }
+
+// resizeImage scales img to fit within resize's bounds according to its Fit
+// mode, never upscaling beyond the source dimensions. "scale" is the one
+// exception: it stretches non-uniformly to the exact target box, so it may
+// grow one or both dimensions even though the other fit modes wouldn't.
+func resizeImage(img image.Image, resize ResizeOptions) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := targetDimensions(srcW, srcH, resize)
+	if resize.Fit != "scale" && dstW >= srcW && dstH >= srcH {
+		return img
+	}
+	if dstW == srcW && dstH == srcH {
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func targetDimensions(srcW, srcH int, resize ResizeOptions) (int, int) {
+	maxW, maxH := resize.MaxWidth, resize.MaxHeight
+	if maxW <= 0 {
+		maxW = srcW
+	}
+	if maxH <= 0 {
+		maxH = srcH
+	}
+
+	// "scale" stretches to the exact target box, ignoring aspect ratio; it's
+	// the one fit mode that isn't a uniform ratio of the source dimensions.
+	if resize.Fit == "scale" {
+		return maxW, maxH
+	}
+
+	ratio := math.Min(float64(maxW)/float64(srcW), float64(maxH)/float64(srcH))
+	if resize.Fit == "cover" {
+		ratio = math.Max(float64(maxW)/float64(srcW), float64(maxH)/float64(srcH))
+	}
+	if ratio >= 1 {
+		return srcW, srcH
+	}
+	return int(float64(srcW) * ratio), int(float64(srcH) * ratio)
+}
+
+// resizeAnimatedGIF resizes each frame of an animated GIF independently,
+// preserving its per-frame palette and timing.
+func resizeAnimatedGIF(raw []byte, resize ResizeOptions) ([]byte, string, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", fmt.Errorf("gif decode error: %w", err)
+	}
+
+	for i, frame := range g.Image {
+		resized := resizeImage(frame, resize)
+		paletted := image.NewPaletted(resized.Bounds(), frame.Palette)
+		draw.Draw(paletted, resized.Bounds(), resized, image.Point{}, draw.Src)
+		g.Image[i] = paletted
+	}
+	if len(g.Image) > 0 {
+		g.Config.Width = g.Image[0].Bounds().Dx()
+		g.Config.Height = g.Image[0].Bounds().Dy()
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, "", fmt.Errorf("gif encode error: %w", err)
+	}
+	return buf.Bytes(), ".gif", nil
+}
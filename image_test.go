@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestTargetDimensions(t *testing.T) {
+	tests := []struct {
+		name         string
+		srcW, srcH   int
+		resize       ResizeOptions
+		wantW, wantH int
+	}{
+		{
+			name: "no bounds leaves size untouched",
+			srcW: 800, srcH: 600,
+			resize: ResizeOptions{},
+			wantW:  800, wantH: 600,
+		},
+		{
+			name: "contain shrinks to the tighter dimension",
+			srcW: 800, srcH: 600,
+			resize: ResizeOptions{MaxWidth: 400, MaxHeight: 400},
+			wantW:  400, wantH: 300,
+		},
+		{
+			name: "cover grows to fill both dimensions",
+			srcW: 800, srcH: 600,
+			resize: ResizeOptions{MaxWidth: 400, MaxHeight: 400, Fit: "cover"},
+			wantW:  533, wantH: 400,
+		},
+		{
+			name: "never upscales beyond the source size",
+			srcW: 200, srcH: 100,
+			resize: ResizeOptions{MaxWidth: 800, MaxHeight: 800},
+			wantW:  200, wantH: 100,
+		},
+		{
+			name: "only max width constrains an unbounded height",
+			srcW: 1000, srcH: 500,
+			resize: ResizeOptions{MaxWidth: 200},
+			wantW:  200, wantH: 100,
+		},
+		{
+			name: "scale stretches non-uniformly to the exact target box",
+			srcW: 800, srcH: 600,
+			resize: ResizeOptions{MaxWidth: 400, MaxHeight: 300, Fit: "scale"},
+			wantW:  400, wantH: 300,
+		},
+		{
+			name: "scale can grow one dimension while shrinking the other",
+			srcW: 800, srcH: 400,
+			resize: ResizeOptions{MaxWidth: 300, MaxHeight: 600, Fit: "scale"},
+			wantW:  300, wantH: 600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotW, gotH := targetDimensions(tt.srcW, tt.srcH, tt.resize)
+			if gotW != tt.wantW || gotH != tt.wantH {
+				t.Errorf("targetDimensions(%d, %d, %+v) = (%d, %d), want (%d, %d)",
+					tt.srcW, tt.srcH, tt.resize, gotW, gotH, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestResizeImageNeverUpscales(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	resized := resizeImage(img, ResizeOptions{MaxWidth: 1000, MaxHeight: 1000})
+
+	bounds := resized.Bounds()
+	if bounds.Dx() != 100 || bounds.Dy() != 50 {
+		t.Errorf("resizeImage upscaled to %dx%d, want unchanged 100x50", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestResizeImageShrinksToFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	resized := resizeImage(img, ResizeOptions{MaxWidth: 100, MaxHeight: 100})
+
+	bounds := resized.Bounds()
+	if bounds.Dx() != 100 || bounds.Dy() != 50 {
+		t.Errorf("resizeImage produced %dx%d, want 100x50", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestResizeImageScaleCanUpscaleOneDimension(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	resized := resizeImage(img, ResizeOptions{MaxWidth: 100, MaxHeight: 300, Fit: "scale"})
+
+	bounds := resized.Bounds()
+	if bounds.Dx() != 100 || bounds.Dy() != 300 {
+		t.Errorf("resizeImage produced %dx%d, want 100x300 (scale stretches non-uniformly)", bounds.Dx(), bounds.Dy())
+	}
+}
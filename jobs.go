@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// JobStatus describes the state of a single image within a download job.
+type JobStatus string
+
+const (
+	JobStatusDownloading JobStatus = "downloading"
+	JobStatusDone        JobStatus = "done"
+	JobStatusError       JobStatus = "error"
+)
+
+// ProgressEvent is broadcast to /progress/{jobID} subscribers as each image
+// in a zip job starts, finishes, or fails.
+type ProgressEvent struct {
+	Index  int       `json:"index"`
+	URL    string    `json:"url"`
+	Status JobStatus `json:"status"`
+	Bytes  int       `json:"bytes"`
+}
+
+// zipJob tracks a single /download-zip request from creation through to the
+// follow-up /download-zip/{id}/archive call that streams the finished zip.
+type zipJob struct {
+	id     string
+	items  []GalleryItem
+	format string
+	resize ResizeOptions
+	title  string
+
+	// ctx governs the in-flight downloads; cancel stops them early if the
+	// job is abandoned (nobody ever polls progress or fetches the archive).
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// events is sized to hold every event the job will ever publish (two
+	// per item: downloading, then done/error), so a worker's send never
+	// blocks even if no one is subscribed to /progress/{id}.
+	events chan ProgressEvent
+	done   chan struct{}
+
+	// claimed is closed the first time the finished archive is fetched, so
+	// the reaper can free the job promptly instead of waiting out its TTL.
+	claimed   chan struct{}
+	claimOnce sync.Once
+
+	// results is only safe to read after done is closed.
+	results []imageResult
+}
+
+func newZipJob(items []GalleryItem, format string, resize ResizeOptions, title string) *zipJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &zipJob{
+		id:      newJobID(),
+		items:   items,
+		format:  format,
+		resize:  resize,
+		title:   title,
+		ctx:     ctx,
+		cancel:  cancel,
+		events:  make(chan ProgressEvent, len(items)*2),
+		done:    make(chan struct{}),
+		claimed: make(chan struct{}),
+	}
+}
+
+// markClaimed signals that the finished archive has been fetched at least
+// once. Safe to call more than once.
+func (j *zipJob) markClaimed() {
+	j.claimOnce.Do(func() { close(j.claimed) })
+}
+
+func newJobID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// jobStore holds in-flight zip jobs, keyed by ID. A job is removed once its
+// archive has been served, or once reapJob's TTL expires for one nobody
+// ever claims.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*zipJob
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*zipJob)}
+}
+
+func (s *jobStore) add(job *zipJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.id] = job
+}
+
+func (s *jobStore) get(id string) (*zipJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func (s *jobStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+}
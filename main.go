@@ -5,21 +5,41 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+
+	"reddit-gallery-dl/store"
 )
 
+const defaultCacheMaxBytes = 1 << 30 // 1 GiB
+
 func main() {
 	tmpl, err := template.ParseGlob("templates/*.html")
 	if err != nil {
 		log.Fatalf("Failed to parse templates: %v", err)
 	}
 
+	history, err := store.Open("./data/history.db", "./data/cache", cacheMaxBytes())
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	defer history.Close()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "5000"
 	}
 
 	log.Printf("Starting Reddit Gallery DL on port %s...", port)
-	if err := http.ListenAndServe(":"+port, NewServer(tmpl).Routes()); err != nil {
+	if err := http.ListenAndServe(":"+port, NewServer(tmpl, history).Routes()); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func cacheMaxBytes() int64 {
+	if v := os.Getenv("CACHE_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultCacheMaxBytes
+}
@@ -9,7 +9,10 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,6 +20,8 @@ import (
 const (
 	userAgent      = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 	defaultTimeout = 120 * time.Second
+
+	defaultImageConcurrency = 6
 )
 
 var (
@@ -27,18 +32,84 @@ var (
 
 type RedditClient struct {
 	client *http.Client
+
+	// imageSemaphore bounds how many StreamImage downloads run concurrently.
+	imageSemaphore chan struct{}
+
+	// resolvers maps a host suffix (e.g. "imgur.com") to the Resolver that
+	// expands it into directly-downloadable media URLs.
+	resolvers map[string]Resolver
 }
 
 func NewRedditClient() *RedditClient {
-	return &RedditClient{
-		client: &http.Client{Timeout: defaultTimeout},
+	httpClient := &http.Client{Timeout: defaultTimeout}
+	r := &RedditClient{
+		client:         httpClient,
+		imageSemaphore: make(chan struct{}, imageConcurrency()),
+		resolvers:      make(map[string]Resolver),
+	}
+
+	r.RegisterResolver("imgur.com", NewImgurResolver(httpClient))
+	r.RegisterResolver("gfycat.com", NewGfycatResolver(httpClient))
+	r.RegisterResolver("redgifs.com", NewRedgifsResolver(httpClient))
+
+	return r
+}
+
+func imageConcurrency() int {
+	if v := os.Getenv("REDDIT_IMAGE_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultImageConcurrency
+}
+
+// acquireImageSlot blocks until a worker slot is free or ctx is done.
+func (r *RedditClient) acquireImageSlot(ctx context.Context) error {
+	select {
+	case r.imageSemaphore <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
+// releaseImageSlot frees a slot acquired via acquireImageSlot.
+func (r *RedditClient) releaseImageSlot() {
+	<-r.imageSemaphore
+}
+
 type Gallery struct {
-	Title  string
-	Images []string
-	URL    string
+	Title     string
+	Images    []GalleryItem
+	URL       string
+	Subreddit string
+	Author    string
+}
+
+// MediaKind distinguishes a still image from a v.redd.it video within a Gallery.
+type MediaKind string
+
+const (
+	MediaKindImage MediaKind = "image"
+	MediaKindVideo MediaKind = "video"
+)
+
+// GalleryItem is one downloadable piece of media from a post.
+type GalleryItem struct {
+	URL  string
+	Kind MediaKind
+
+	// DashURL is set for videos whose audio and video are published as
+	// separate DASH streams; StreamVideo muxes them together if ffmpeg is
+	// available, otherwise falls back to URL (the pre-muxed fallback mp4).
+	DashURL string
+
+	// HLSURL is a v.redd.it HLS manifest (audio and video already combined).
+	// StreamVideo muxes it into an mp4 with ffmpeg when there's no DashURL to
+	// prefer, otherwise it's only used as a last resort before URL.
+	HLSURL string
 }
 
 type redditResponse []struct {
@@ -51,6 +122,8 @@ type redditResponse []struct {
 
 type redditPost struct {
 	Title       string `json:"title"`
+	Subreddit   string `json:"subreddit"`
+	Author      string `json:"author"`
 	IsGallery   bool   `json:"is_gallery"`
 	URL         string `json:"url_overridden_by_dest"`
 	GalleryData *struct {
@@ -72,6 +145,13 @@ type redditPost struct {
 			} `json:"variants"`
 		} `json:"images"`
 	} `json:"preview"`
+	SecureMedia *struct {
+		RedditVideo *struct {
+			DashURL     string `json:"dash_url"`
+			HLSURL      string `json:"hls_url"`
+			FallbackURL string `json:"fallback_url"`
+		} `json:"reddit_video"`
+	} `json:"secure_media"`
 }
 
 func (r *RedditClient) makeRequest(ctx context.Context, method, targetURL string) (*http.Response, error) {
@@ -110,12 +190,19 @@ func (r *RedditClient) FetchGallery(ctx context.Context, postURL string) (*Galle
 	}
 
 	post := data[0].Data.Children[0].Data
-	images := extractImages(post)
-	if len(images) == 0 {
+	items := extractMedia(post)
+	if len(items) == 0 {
 		return nil, ErrNoImages
 	}
+	items = r.resolveItems(ctx, items)
 
-	return &Gallery{Title: post.Title, Images: images, URL: postURL}, nil
+	return &Gallery{
+		Title:     post.Title,
+		Images:    items,
+		URL:       postURL,
+		Subreddit: post.Subreddit,
+		Author:    post.Author,
+	}, nil
 }
 
 func (r *RedditClient) resolveURL(ctx context.Context, inputURL string) (string, error) {
@@ -158,8 +245,113 @@ func (r *RedditClient) StreamImage(ctx context.Context, urlStr string) (io.ReadC
 	return resp.Body, detectExtension(urlStr, resp.Header.Get("Content-Type")), nil
 }
 
-func extractImages(post redditPost) []string {
-	var images []string
+// StreamVideo returns a readable stream for a video GalleryItem. If ffmpeg is
+// available (via $FFMPEG_PATH or $PATH) and item has a DashURL, it muxes the
+// separate DASH video and audio streams into an mp4. Failing that, if item
+// has an HLSURL it muxes that instead (audio and video already combined, so
+// ffmpeg just needs to remux it into an mp4 container). Otherwise it falls
+// back to streaming item.URL, the pre-muxed fallback mp4 (video-only, no audio).
+func (r *RedditClient) StreamVideo(ctx context.Context, item GalleryItem) (io.ReadCloser, error) {
+	if ffmpegPath, ok := ffmpegPath(); ok {
+		if item.DashURL != "" {
+			return muxDashVideo(ctx, ffmpegPath, item.DashURL)
+		}
+		if item.HLSURL != "" {
+			return muxHLSVideo(ctx, ffmpegPath, item.HLSURL)
+		}
+	}
+
+	if item.URL == "" {
+		return nil, fmt.Errorf("no playable video url")
+	}
+	resp, err := r.makeRequest(ctx, "GET", item.URL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// ffmpegPath resolves the ffmpeg binary to invoke, preferring $FFMPEG_PATH,
+// and reports whether it's actually usable.
+func ffmpegPath() (string, bool) {
+	ffmpegPath := os.Getenv("FFMPEG_PATH")
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	if _, err := exec.LookPath(ffmpegPath); err != nil {
+		return "", false
+	}
+	return ffmpegPath, true
+}
+
+// muxDashVideo shells out to ffmpeg to combine a v.redd.it DASH video stream
+// with its separately-hosted audio track into a single mp4.
+func muxDashVideo(ctx context.Context, ffmpegPath, dashURL string) (io.ReadCloser, error) {
+	return runFFmpegMux(ctx, ffmpegPath, "-i", dashURL, "-i", dashAudioURL(dashURL))
+}
+
+// muxHLSVideo shells out to ffmpeg to remux a v.redd.it HLS manifest (audio
+// and video already combined) into a single mp4.
+func muxHLSVideo(ctx context.Context, ffmpegPath, hlsURL string) (io.ReadCloser, error) {
+	return runFFmpegMux(ctx, ffmpegPath, "-i", hlsURL)
+}
+
+// runFFmpegMux runs ffmpeg with the given input args plus a stream-copy mp4
+// output, writing to a temp file whose ReadCloser deletes it on Close.
+func runFFmpegMux(ctx context.Context, ffmpegPath string, inputArgs ...string) (io.ReadCloser, error) {
+	tmp, err := os.CreateTemp("", "reddit-video-*.mp4")
+	if err != nil {
+		return nil, fmt.Errorf("temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	args := append([]string{"-y"}, inputArgs...)
+	args = append(args, "-c", "copy", "-movflags", "faststart", tmpPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("ffmpeg mux failed: %w: %s", err, out)
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return &tempFileReadCloser{File: f, path: tmpPath}, nil
+}
+
+// dashAudioURL derives a v.redd.it DASH manifest's audio rendition URL from
+// its video URL; both live under the same post's DASH_ prefix.
+func dashAudioURL(dashURL string) string {
+	idx := strings.LastIndex(dashURL, "/")
+	if idx == -1 {
+		return dashURL
+	}
+	return dashURL[:idx+1] + "DASH_audio.mp4"
+}
+
+// tempFileReadCloser deletes its backing file once closed, so StreamVideo
+// callers don't need to know a temp file was involved.
+type tempFileReadCloser struct {
+	*os.File
+	path string
+}
+
+func (t *tempFileReadCloser) Close() error {
+	err := t.File.Close()
+	os.Remove(t.path)
+	return err
+}
+
+func extractMedia(post redditPost) []GalleryItem {
+	var items []GalleryItem
 
 	if post.IsGallery && post.GalleryData != nil {
 		for _, item := range post.GalleryData.Items {
@@ -169,25 +361,30 @@ func extractImages(post redditPost) []string {
 					raw = media.S.U
 				}
 				if raw != "" {
-					images = append(images, strings.ReplaceAll(raw, "&amp;", "&"))
+					items = append(items, GalleryItem{URL: strings.ReplaceAll(raw, "&amp;", "&"), Kind: MediaKindImage})
 				}
 			}
 		}
 	}
 
-	if len(images) == 0 && post.Preview != nil {
+	if len(items) == 0 && post.Preview != nil {
 		for _, img := range post.Preview.Images {
 			if img.Variants.Gif != nil {
-				images = append(images, strings.ReplaceAll(img.Variants.Gif.Source.URL, "&amp;", "&"))
+				items = append(items, GalleryItem{URL: strings.ReplaceAll(img.Variants.Gif.Source.URL, "&amp;", "&"), Kind: MediaKindImage})
 			}
 		}
 	}
 
-	if len(images) == 0 && post.URL != "" {
-		images = append(images, strings.ReplaceAll(post.URL, "&amp;", "&"))
+	if len(items) == 0 && post.SecureMedia != nil && post.SecureMedia.RedditVideo != nil {
+		video := post.SecureMedia.RedditVideo
+		items = append(items, GalleryItem{URL: video.FallbackURL, DashURL: video.DashURL, HLSURL: video.HLSURL, Kind: MediaKindVideo})
+	}
+
+	if len(items) == 0 && post.URL != "" {
+		items = append(items, GalleryItem{URL: strings.ReplaceAll(post.URL, "&amp;", "&"), Kind: MediaKindImage})
 	}
 
-	return images
+	return items
 }
 
 func detectExtension(urlStr, contentType string) string {
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// MediaItem is a single directly-downloadable media URL produced by a
+// Resolver, expanded from an album/post URL on a third-party host.
+type MediaItem struct {
+	URL string
+}
+
+// Resolver expands a post or album URL from a third-party host into one or
+// more directly-downloadable media URLs. Reddit posts frequently link to
+// hosts like Imgur or Redgifs instead of a bare image, and those need their
+// own API call to find the actual media.
+type Resolver interface {
+	Resolve(ctx context.Context, rawURL string) ([]MediaItem, error)
+}
+
+// passthroughResolver returns the URL unchanged. It's the implicit fallback
+// for any host without a registered Resolver.
+type passthroughResolver struct{}
+
+func (passthroughResolver) Resolve(_ context.Context, rawURL string) ([]MediaItem, error) {
+	return []MediaItem{{URL: rawURL}}, nil
+}
+
+// ImgurResolver expands Imgur albums and galleries via the public Imgur API.
+type ImgurResolver struct {
+	client   *http.Client
+	clientID string
+}
+
+func NewImgurResolver(client *http.Client) *ImgurResolver {
+	return &ImgurResolver{client: client, clientID: os.Getenv("IMGUR_CLIENT_ID")}
+}
+
+func (r *ImgurResolver) Resolve(ctx context.Context, rawURL string) ([]MediaItem, error) {
+	id, ok := imgurAlbumID(rawURL)
+	if !ok {
+		return []MediaItem{{URL: rawURL}}, nil
+	}
+	if r.clientID == "" {
+		return nil, fmt.Errorf("imgur resolver: IMGUR_CLIENT_ID not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.imgur.com/3/album/%s", id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("imgur request: %w", err)
+	}
+	req.Header.Set("Authorization", "Client-ID "+r.clientID)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("imgur request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("imgur api status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Images []struct {
+				Link string `json:"link"`
+			} `json:"images"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("imgur json decode: %w", err)
+	}
+
+	items := make([]MediaItem, 0, len(body.Data.Images))
+	for _, img := range body.Data.Images {
+		items = append(items, MediaItem{URL: strings.Replace(img.Link, ".gifv", ".mp4", 1)})
+	}
+	return items, nil
+}
+
+func imgurAlbumID(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, p := range parts {
+		if (p == "a" || p == "gallery") && i+1 < len(parts) {
+			return parts[i+1], true
+		}
+	}
+	return "", false
+}
+
+// GfycatResolver expands a Gfycat or Redgifs watch page into its direct mp4
+// URL via each site's public gfy-item API.
+type GfycatResolver struct {
+	client  *http.Client
+	apiBase string
+}
+
+func NewGfycatResolver(client *http.Client) *GfycatResolver {
+	return &GfycatResolver{client: client, apiBase: "https://api.gfycat.com/v1/gfycats"}
+}
+
+func NewRedgifsResolver(client *http.Client) *GfycatResolver {
+	return &GfycatResolver{client: client, apiBase: "https://api.redgifs.com/v2/gifs"}
+}
+
+func (r *GfycatResolver) Resolve(ctx context.Context, rawURL string) ([]MediaItem, error) {
+	id, ok := gfyItemID(rawURL)
+	if !ok {
+		return []MediaItem{{URL: rawURL}}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s", r.apiBase, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("gfycat request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gfycat request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gfycat api status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		GfyItem struct {
+			Mp4URL string `json:"mp4Url"`
+		} `json:"gfyItem"`
+		Gif struct {
+			Urls struct {
+				HD string `json:"hd"`
+			} `json:"urls"`
+		} `json:"gif"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("gfycat json decode: %w", err)
+	}
+
+	mp4 := body.GfyItem.Mp4URL
+	if mp4 == "" {
+		mp4 = body.Gif.Urls.HD
+	}
+	if mp4 == "" {
+		return nil, fmt.Errorf("gfycat: no mp4 url in response")
+	}
+	return []MediaItem{{URL: mp4}}, nil
+}
+
+func gfyItemID(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	id := path.Base(strings.TrimRight(u.Path, "/"))
+	if id == "" || id == "." {
+		return "", false
+	}
+	return id, true
+}
+
+// RegisterResolver associates a host suffix (e.g. "imgur.com") with a
+// Resolver. Matching is by exact host or subdomain suffix, so registering
+// "imgur.com" also covers "i.imgur.com" and "m.imgur.com".
+func (r *RedditClient) RegisterResolver(host string, res Resolver) {
+	r.resolvers[host] = res
+}
+
+// resolverFor returns the Resolver registered for rawURL's host, if any.
+func (r *RedditClient) resolverFor(rawURL string) (Resolver, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	for suffix, res := range r.resolvers {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+// resolveItems expands each image GalleryItem through its registered
+// Resolver (if any), leaving unmatched hosts and non-image items untouched.
+func (r *RedditClient) resolveItems(ctx context.Context, items []GalleryItem) []GalleryItem {
+	resolved := make([]GalleryItem, 0, len(items))
+	for _, it := range items {
+		if it.Kind != MediaKindImage {
+			resolved = append(resolved, it)
+			continue
+		}
+
+		res, ok := r.resolverFor(it.URL)
+		if !ok {
+			resolved = append(resolved, it)
+			continue
+		}
+
+		expanded, err := res.Resolve(ctx, it.URL)
+		if err != nil {
+			log.Printf("resolver failed for %s: %v", it.URL, err)
+			resolved = append(resolved, it)
+			continue
+		}
+		for _, m := range expanded {
+			resolved = append(resolved, GalleryItem{URL: m.URL, Kind: MediaKindImage})
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestResolverFor(t *testing.T) {
+	r := &RedditClient{resolvers: map[string]Resolver{
+		"imgur.com":   passthroughResolver{},
+		"redgifs.com": passthroughResolver{},
+	}}
+
+	tests := []struct {
+		name   string
+		rawURL string
+		want   bool
+	}{
+		{"exact match", "https://imgur.com/a/abc123", true},
+		{"subdomain match", "https://i.imgur.com/abc123.jpg", true},
+		{"www is trimmed before matching", "https://www.imgur.com/a/abc123", true},
+		{"unrelated host with matching suffix is not a match", "https://notimgur.com/a/abc123", false},
+		{"unregistered host", "https://i.redd.it/abc123.jpg", false},
+		{"invalid url", "://not-a-url", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := r.resolverFor(tt.rawURL)
+			if ok != tt.want {
+				t.Errorf("resolverFor(%q) ok = %v, want %v", tt.rawURL, ok, tt.want)
+			}
+		})
+	}
+}
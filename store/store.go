@@ -0,0 +1,349 @@
+// Package store persists fetched galleries and caches their images on disk
+// so a repeat visit to the same post can skip hitting Reddit entirely.
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Gallery is a single fetched post recorded in history.
+type Gallery struct {
+	ID         int64
+	PostURL    string
+	Title      string
+	Subreddit  string
+	Author     string
+	FetchedAt  time.Time
+	ImageCount int
+}
+
+// Image is one cached piece of media belonging to a Gallery.
+type Image struct {
+	ID        int64
+	GalleryID int64
+	URL       string
+	CachePath string
+}
+
+// Store is a SQLite-backed history of fetched galleries, with their image
+// bytes cached on disk under a size-capped cache directory.
+type Store struct {
+	db       *sql.DB
+	cacheDir string
+	maxBytes int64
+}
+
+// Open opens (creating if needed) the SQLite database at dbPath and the
+// on-disk image cache at cacheDir, capped at maxBytes (0 disables the cap).
+func Open(dbPath, cacheDir string, maxBytes int64) (*Store, error) {
+	// PRAGMA foreign_keys is per-connection, not per-database, and sql.DB
+	// pools connections it can open lazily at any time; running it once via
+	// db.Exec only covers whichever connection happens to run that Exec, so
+	// DeleteGallery's cascade into images wouldn't be reliably enforced.
+	// busy_timeout makes SQLite retry instead of returning SQLITE_BUSY
+	// immediately when two pooled connections write at once. Setting both
+	// in the DSN applies them to every connection the pool opens, without
+	// forcing the whole pool down to one connection.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+
+	s := &Store{db: db, cacheDir: cacheDir, maxBytes: maxBytes}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+CREATE TABLE IF NOT EXISTS galleries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_url TEXT NOT NULL,
+	title TEXT NOT NULL,
+	subreddit TEXT NOT NULL,
+	author TEXT NOT NULL,
+	fetched_at DATETIME NOT NULL,
+	image_count INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS images (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	gallery_id INTEGER NOT NULL REFERENCES galleries(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	cache_path TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_galleries_subreddit ON galleries(subreddit);
+CREATE INDEX IF NOT EXISTS idx_galleries_fetched_at ON galleries(fetched_at);
+`)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// FetchImage returns an image's bytes and file extension, given its URL.
+// SaveGallery uses it to populate the cache without depending on the reddit
+// package directly.
+type FetchImage func(url string) (data []byte, ext string, err error)
+
+// cachedImage is an image already fetched and written to the cache
+// directory, pending its row insert inside SaveGallery's transaction.
+type cachedImage struct {
+	url       string
+	cachePath string
+}
+
+// SaveGallery records a fetched gallery and best-effort caches each image's
+// bytes to disk via fetch. A caching failure for one image doesn't fail the
+// whole save; the history row still records the URL.
+//
+// Every image is fetched and cached before the transaction opens, so the
+// write lock it holds only spans the row inserts, not the network calls
+// fetch makes. Without that, concurrent callers (one per gallery page load)
+// would serialize behind each other's in-flight fetches and risk SQLITE_BUSY
+// under SQLite's single-writer semantics.
+func (s *Store) SaveGallery(ctx context.Context, g Gallery, imageURLs []string, fetch FetchImage) (int64, error) {
+	cached := make([]cachedImage, 0, len(imageURLs))
+	for _, u := range imageURLs {
+		data, ext, err := fetch(u)
+		if err != nil {
+			continue
+		}
+		cachePath, err := s.writeCache(data, ext)
+		if err != nil {
+			continue
+		}
+		cached = append(cached, cachedImage{url: u, cachePath: cachePath})
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO galleries (post_url, title, subreddit, author, fetched_at, image_count) VALUES (?, ?, ?, ?, ?, ?)`,
+		g.PostURL, g.Title, g.Subreddit, g.Author, g.FetchedAt, len(imageURLs))
+	if err != nil {
+		return 0, fmt.Errorf("insert gallery: %w", err)
+	}
+	galleryID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert gallery: %w", err)
+	}
+
+	for _, img := range cached {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO images (gallery_id, url, cache_path) VALUES (?, ?, ?)`,
+			galleryID, img.url, img.cachePath); err != nil {
+			return 0, fmt.Errorf("insert image: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+
+	return galleryID, s.enforceCacheCap(ctx)
+}
+
+// writeCache stores data under a content-addressed filename, so repeated
+// downloads of the same bytes reuse one cache entry.
+func (s *Store) writeCache(data []byte, ext string) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	fullPath := filepath.Join(s.cacheDir, name)
+
+	if _, err := os.Stat(fullPath); err == nil {
+		return fullPath, nil
+	}
+	if err := os.WriteFile(fullPath, data, 0o644); err != nil {
+		return "", fmt.Errorf("write cache: %w", err)
+	}
+	return fullPath, nil
+}
+
+// enforceCacheCap evicts the oldest cache files not referenced by any image
+// row, least-recently-modified first, until the cache directory is back
+// under maxBytes. Files a history entry still points at are left alone even
+// past the cap, so GetGallery never returns a cache_path that's gone missing
+// out from under it.
+func (s *Store) enforceCacheCap(ctx context.Context) error {
+	if s.maxBytes <= 0 {
+		return nil
+	}
+
+	referenced, err := s.referencedCachePaths(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return fmt.Errorf("read cache dir: %w", err)
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var evictable []cacheFile
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		path := filepath.Join(s.cacheDir, e.Name())
+		if referenced[path] {
+			continue
+		}
+		evictable = append(evictable, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+	if total <= s.maxBytes {
+		return nil
+	}
+
+	sort.Slice(evictable, func(i, j int) bool { return evictable[i].modTime.Before(evictable[j].modTime) })
+	for _, f := range evictable {
+		if total <= s.maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			continue
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// referencedCachePaths returns the set of cache file paths still pointed at
+// by a live images row.
+func (s *Store) referencedCachePaths(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT cache_path FROM images`)
+	if err != nil {
+		return nil, fmt.Errorf("list referenced cache paths: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("scan cache path: %w", err)
+		}
+		paths[p] = true
+	}
+	return paths, rows.Err()
+}
+
+// ListGalleries returns galleries newest-first, optionally filtered by
+// subreddit, paginated by limit/offset.
+func (s *Store) ListGalleries(ctx context.Context, subreddit string, limit, offset int) ([]Gallery, error) {
+	query := `SELECT id, post_url, title, subreddit, author, fetched_at, image_count FROM galleries`
+	var args []any
+	if subreddit != "" {
+		query += ` WHERE subreddit = ?`
+		args = append(args, subreddit)
+	}
+	query += ` ORDER BY fetched_at DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list galleries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Gallery
+	for rows.Next() {
+		var g Gallery
+		if err := rows.Scan(&g.ID, &g.PostURL, &g.Title, &g.Subreddit, &g.Author, &g.FetchedAt, &g.ImageCount); err != nil {
+			return nil, fmt.Errorf("scan gallery: %w", err)
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// GetGallery returns a single gallery and its cached images.
+func (s *Store) GetGallery(ctx context.Context, id int64) (*Gallery, []Image, error) {
+	var g Gallery
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, post_url, title, subreddit, author, fetched_at, image_count FROM galleries WHERE id = ?`, id,
+	).Scan(&g.ID, &g.PostURL, &g.Title, &g.Subreddit, &g.Author, &g.FetchedAt, &g.ImageCount)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get gallery: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, gallery_id, url, cache_path FROM images WHERE gallery_id = ?`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []Image
+	for rows.Next() {
+		var img Image
+		if err := rows.Scan(&img.ID, &img.GalleryID, &img.URL, &img.CachePath); err != nil {
+			return nil, nil, fmt.Errorf("scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+	return &g, images, rows.Err()
+}
+
+// GetImage returns a single cached image, scoped to the gallery it's
+// expected to belong to so a caller can't be handed an image from a
+// different gallery by guessing its ID.
+func (s *Store) GetImage(ctx context.Context, galleryID, imageID int64) (*Image, error) {
+	var img Image
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, gallery_id, url, cache_path FROM images WHERE id = ? AND gallery_id = ?`, imageID, galleryID,
+	).Scan(&img.ID, &img.GalleryID, &img.URL, &img.CachePath)
+	if err != nil {
+		return nil, fmt.Errorf("get image: %w", err)
+	}
+	return &img, nil
+}
+
+// DeleteGallery removes a gallery and its image rows (cascading via the
+// foreign key). Cached files on disk are left for enforceCacheCap to evict.
+func (s *Store) DeleteGallery(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM galleries WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete gallery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete gallery: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("gallery %d not found", id)
+	}
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fakeFetch(u string) ([]byte, string, error) {
+	return []byte("data-for-" + u), ".jpg", nil
+}
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "history.db"), filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSaveGetDeleteGalleryRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	urls := []string{"https://example.com/a.jpg", "https://example.com/b.jpg"}
+	record := Gallery{
+		PostURL:   "https://reddit.com/r/test/comments/abc",
+		Title:     "Test Gallery",
+		Subreddit: "test",
+		Author:    "someone",
+		FetchedAt: time.Now(),
+	}
+
+	id, err := s.SaveGallery(ctx, record, urls, fakeFetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+
+	gallery, images, err := s.GetGallery(ctx, id)
+	if err != nil {
+		t.Fatalf("GetGallery: %v", err)
+	}
+	if gallery.Title != record.Title || gallery.Subreddit != record.Subreddit {
+		t.Errorf("GetGallery returned %+v, want matching %+v", gallery, record)
+	}
+	if len(images) != len(urls) {
+		t.Fatalf("GetGallery returned %d images, want %d", len(images), len(urls))
+	}
+	for _, img := range images {
+		if _, err := os.Stat(img.CachePath); err != nil {
+			t.Errorf("cached file %s missing: %v", img.CachePath, err)
+		}
+	}
+
+	if err := s.DeleteGallery(ctx, id); err != nil {
+		t.Fatalf("DeleteGallery: %v", err)
+	}
+	if _, _, err := s.GetGallery(ctx, id); err == nil {
+		t.Error("GetGallery succeeded after DeleteGallery, want an error")
+	}
+}
+
+func TestDeleteGalleryCascadesImages(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	id, err := s.SaveGallery(ctx, Gallery{
+		PostURL:   "https://reddit.com/r/test/comments/def",
+		Title:     "Cascade Gallery",
+		Subreddit: "test",
+		Author:    "someone",
+		FetchedAt: time.Now(),
+	}, []string{"https://example.com/c.jpg"}, fakeFetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+
+	var imageCount int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM images WHERE gallery_id = ?`, id).Scan(&imageCount); err != nil {
+		t.Fatalf("count images before delete: %v", err)
+	}
+	if imageCount != 1 {
+		t.Fatalf("got %d images before delete, want 1", imageCount)
+	}
+
+	if err := s.DeleteGallery(ctx, id); err != nil {
+		t.Fatalf("DeleteGallery: %v", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM images WHERE gallery_id = ?`, id).Scan(&imageCount); err != nil {
+		t.Fatalf("count images after delete: %v", err)
+	}
+	if imageCount != 0 {
+		t.Errorf("got %d images after delete, want 0 (foreign key cascade should remove them)", imageCount)
+	}
+}
+
+func TestGetImageScopedToOwningGallery(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	id1, err := s.SaveGallery(ctx, Gallery{PostURL: "p1", Title: "g1", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/a.jpg"}, fakeFetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+	id2, err := s.SaveGallery(ctx, Gallery{PostURL: "p2", Title: "g2", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/b.jpg"}, fakeFetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+
+	_, images1, err := s.GetGallery(ctx, id1)
+	if err != nil || len(images1) != 1 {
+		t.Fatalf("GetGallery(id1): images=%v err=%v", images1, err)
+	}
+
+	img, err := s.GetImage(ctx, id1, images1[0].ID)
+	if err != nil {
+		t.Fatalf("GetImage(id1, its own image): %v", err)
+	}
+	if img.ID != images1[0].ID {
+		t.Errorf("GetImage returned image %d, want %d", img.ID, images1[0].ID)
+	}
+
+	if _, err := s.GetImage(ctx, id2, images1[0].ID); err == nil {
+		t.Error("GetImage succeeded for an image belonging to a different gallery, want an error")
+	}
+}
+
+func TestEnforceCacheCapSkipsReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "history.db"), filepath.Join(dir, "cache"), 10)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	ctx := context.Background()
+
+	fetch := func(u string) ([]byte, string, error) {
+		return []byte("0123456789ABCDEF-" + u), ".jpg", nil
+	}
+
+	id1, err := s.SaveGallery(ctx, Gallery{PostURL: "p1", Title: "g1", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/a.jpg"}, fetch)
+	if err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+	if _, err := s.SaveGallery(ctx, Gallery{PostURL: "p2", Title: "g2", Subreddit: "s", Author: "a", FetchedAt: time.Now()}, []string{"https://example.com/b.jpg"}, fetch); err != nil {
+		t.Fatalf("SaveGallery: %v", err)
+	}
+
+	// Both galleries are well past the 10-byte cap combined, but neither has
+	// been deleted, so both images' cache files must still be on disk.
+	_, images, err := s.GetGallery(ctx, id1)
+	if err != nil || len(images) != 1 {
+		t.Fatalf("GetGallery(id1): images=%v err=%v", images, err)
+	}
+	if _, err := os.Stat(images[0].CachePath); err != nil {
+		t.Errorf("cache cap evicted a still-referenced file: %v", err)
+	}
+}